@@ -0,0 +1,48 @@
+package admission
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_AdmissionController_BackoffOnSustainedErrors(t *testing.T) {
+	ac := NewAdmissionController(WithInitialLimit(20))
+
+	for i := 0; i < 5; i++ {
+		token, ok := ac.Allow()
+		if !ok {
+			t.Fatalf("Allow() rejected request %d, expected room under the initial limit of 20", i)
+		}
+		token.Done(false, time.Millisecond)
+	}
+
+	if ac.limit >= 20 {
+		t.Fatalf("limit = %v, want it to have backed off below the initial 20 after sustained failures", ac.limit)
+	}
+}
+
+// Test_AdmissionController_ProbesUpAtCeiling drives done, and through it
+// adjustLimit, directly with
+// a healthy (no-load-matching) latency sample and inflight left at the limit,
+// the situation the additive probe in adjustLimit exists for: the
+// multiplicative gradient alone converges to the ceiling too slowly, so a
+// service sitting right at its limit with no sign of queueing should still
+// creep the limit upward instead of sticking there forever.
+func Test_AdmissionController_ProbesUpAtCeiling(t *testing.T) {
+	ac := NewAdmissionController(WithInitialLimit(5))
+
+	const noLoadLatency = time.Millisecond * 10
+	ac.rttFloor.observe(float64(noLoadLatency))
+	ac.latency.Update(float64(noLoadLatency))
+
+	// as if one more request was admitted than the limit currently allows,
+	// e.g. because the limit was only just lowered while requests already in
+	// flight hadn't completed yet
+	ac.inflight = 6
+
+	ac.done(true, noLoadLatency)
+
+	if ac.limit <= 5 {
+		t.Fatalf("limit = %v, want it to probe upward past 5 while healthy and running at the limit", ac.limit)
+	}
+}
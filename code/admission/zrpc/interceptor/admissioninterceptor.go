@@ -0,0 +1,31 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"gozerosource/code/admission/core/load/admission"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryAdmissionInterceptor builds a grpc.UnaryServerInterceptor that sheds
+// load through ac before invoking the handler, the zrpc-side counterpart of
+// handler.AdmissionHandler for rest.
+// zrpc 版的自适应并发准入拦截器
+func UnaryAdmissionInterceptor(ac *admission.AdmissionController) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		token, ok := ac.Allow()
+		if !ok {
+			return nil, status.Error(codes.ResourceExhausted, "admission: concurrency limit reached")
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		token.Done(err == nil, time.Since(start))
+		return resp, err
+	}
+}
@@ -0,0 +1,234 @@
+package collection
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/timex"
+)
+
+// offsetBits is how many high bits of the packed state word are spent on the
+// bucket offset; the remaining low bits hold lastTime at microsecond
+// resolution, which is plenty for span calculations against interval-sized
+// buckets.
+const (
+	offsetBits   = 16
+	lastTimeBits = 64 - offsetBits
+	lastTimeMask = 1<<lastTimeBits - 1
+	lastTimeUnit = time.Microsecond
+)
+
+type (
+	// AtomicRollingWindowOption let callers customize the AtomicRollingWindow.
+	AtomicRollingWindowOption func(w *AtomicRollingWindow)
+
+	// atomicBucket holds one bucket's data behind its own mutex. claimedAt
+	// records which interval (in lastTimeUnit resolution, like packState's
+	// lastTime) the bucket's data currently belongs to, so a bucket is reset
+	// at most once per interval regardless of how many goroutines race to
+	// claim it; see ensureAssigned. The mutex is scoped to a single bucket
+	// rather than the whole window, so it only contends among the (typically
+	// few) goroutines landing in the same bucket at the same moment, unlike
+	// RollingWindow's single lock across every bucket.
+	atomicBucket struct {
+		mu        sync.Mutex
+		sum       float64
+		count     int64
+		claimedAt int64
+	}
+
+	// AtomicRollingWindow is a drop-in alternative to RollingWindow for hot
+	// paths (limiter/breaker/balancer) where many goroutines call Add
+	// concurrently. It packs (offset, lastTime) into a single atomic.Uint64
+	// and advances the window with a CAS loop, so figuring out which bucket
+	// is current never blocks; only the rare bucket reset plus the bucket's
+	// own sum/count update take that one bucket's mutex, so concurrent Add
+	// calls landing in different buckets never wait on each other. Prefer
+	// RollingWindow where a single global lock across every bucket is
+	// acceptable and simplicity matters more than sharding that contention.
+	// 滑动窗口：用 CAS 无锁地推进窗口 offset，仅在单个 bucket 内部用锁保护
+	// sum/count 的读写，不同 bucket 之间互不阻塞；相比 RollingWindow 的全局锁，
+	// 并发场景下的锁粒度更细
+	AtomicRollingWindow struct {
+		size          int
+		interval      time.Duration
+		buckets       []atomicBucket
+		ignoreCurrent bool
+		state         atomic.Uint64 // packed (offset, lastTime)
+	}
+)
+
+// NewAtomicRollingWindow returns an AtomicRollingWindow with size buckets and
+// the given interval, customized by opts.
+func NewAtomicRollingWindow(size int, interval time.Duration, opts ...AtomicRollingWindowOption) *AtomicRollingWindow {
+	if size < 1 {
+		panic("size must be greater than 0")
+	}
+
+	w := &AtomicRollingWindow{
+		size:     size,
+		interval: interval,
+		buckets:  make([]atomicBucket, size),
+	}
+	w.state.Store(packState(0, timex.Now()))
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// IgnoreCurrentAtomicBucket lets Reduce skip the current, still-filling bucket,
+// mirroring IgnoreCurrentBucket for RollingWindow.
+func IgnoreCurrentAtomicBucket() AtomicRollingWindowOption {
+	return func(w *AtomicRollingWindow) {
+		w.ignoreCurrent = true
+	}
+}
+
+// Add adds v to the current bucket, finding it with a lock-free CAS loop and
+// only taking that one bucket's own lock to record the value.
+func (w *AtomicRollingWindow) Add(v float64) {
+	offset, bucketTime := w.advance()
+	w.buckets[offset].add(v, bucketTime)
+}
+
+// Reduce copies a consistent-ish snapshot of the buckets, oldest first, into
+// buf (which is grown if it is too small) and returns it. Each bucket is
+// snapshotted under its own lock, so an individual bucket's Sum and Count are
+// always a consistent pair, but the buckets aren't all snapshotted at the
+// same instant, so a bucket written concurrently with the call can show up as
+// either its pre- or post-Add value. Callers that can't tolerate that
+// approximation should use RollingWindow instead.
+func (w *AtomicRollingWindow) Reduce(buf []Bucket) []Bucket {
+	offset, lastTime := unpackState(w.state.Load())
+	span := w.span(lastTime)
+
+	var diff int
+	if span == 0 && w.ignoreCurrent {
+		diff = w.size - 1
+	} else {
+		diff = w.size - span
+	}
+	if diff <= 0 {
+		return buf[:0]
+	}
+
+	buf = buf[:0]
+	start := (offset + span + 1) % w.size
+	for i := 0; i < diff; i++ {
+		buf = append(buf, w.buckets[(start+i)%w.size].snapshot())
+	}
+
+	return buf
+}
+
+// span reports how many whole intervals have elapsed since lastTime.
+func (w *AtomicRollingWindow) span(lastTime time.Duration) int {
+	offset := int(timex.Since(lastTime) / w.interval)
+	if 0 <= offset && offset < w.size {
+		return offset
+	}
+
+	return w.size
+}
+
+// advance moves the window forward if needed and returns the current
+// bucket's offset and the interval it belongs to, claiming any buckets that
+// expired along the way. It CASes against the packed state word so
+// concurrent callers never block each other; a losing CAS just retries
+// against the fresher state.
+func (w *AtomicRollingWindow) advance() (int, time.Duration) {
+	for {
+		old := w.state.Load()
+		offset, lastTime := unpackState(old)
+
+		span := w.span(lastTime)
+		if span <= 0 {
+			return offset, lastTime
+		}
+
+		resetFrom := offset
+		newOffset := (offset + span) % w.size
+		now := timex.Now()
+		newLastTime := now - (now-lastTime)%w.interval
+
+		// Claim the expiring buckets for the interval they're about to hold,
+		// rather than unconditionally resetting them: this loop runs before
+		// the CAS below commits, so a goroutine that loses the CAS (or is
+		// simply slow to reach here) can execute it well after a fresher
+		// advance already claimed the same bucket for a later interval, and
+		// an unconditional reset would silently wipe out whatever that
+		// fresher advance, or the Add that followed it, wrote. ensureAssigned
+		// only resets a bucket the first time it's claimed for a given
+		// interval, and does so under that bucket's own lock together with
+		// the reset, so a stale claim arriving late is a no-op instead of a
+		// reset racing a legitimate write.
+		for i := 0; i < span; i++ {
+			bucketTime := lastTime + time.Duration(i+1)*w.interval
+			w.buckets[(resetFrom+i+1)%w.size].ensureAssigned(bucketTime)
+		}
+
+		if !w.state.CompareAndSwap(old, packState(newOffset, newLastTime)) {
+			continue
+		}
+
+		return newOffset, newLastTime
+	}
+}
+
+// add claims the bucket for the interval starting at ts, if it hasn't been
+// already, and adds v to it, all under the bucket's own lock so the claim
+// (and the reset that goes with it) and the add it's guarding can never be
+// reordered against each other.
+func (b *atomicBucket) add(v float64, ts time.Duration) {
+	b.mu.Lock()
+	b.resetIfStale(ts)
+	b.sum += v
+	b.count++
+	b.mu.Unlock()
+}
+
+// ensureAssigned claims the bucket for the interval starting at ts, resetting
+// it the first time it's claimed for that interval and no-oping on every
+// later call for the same or an older ts.
+func (b *atomicBucket) ensureAssigned(ts time.Duration) {
+	b.mu.Lock()
+	b.resetIfStale(ts)
+	b.mu.Unlock()
+}
+
+// resetIfStale must be called with b.mu held. A stale, late-arriving claim
+// (from an interval before whichever one most recently won) is a no-op here
+// instead of clobbering fresher data, since claimedAt only ever moves forward.
+func (b *atomicBucket) resetIfStale(ts time.Duration) {
+	target := int64(ts / lastTimeUnit)
+	if b.claimedAt >= target {
+		return
+	}
+	b.claimedAt = target
+	b.sum = 0
+	b.count = 0
+}
+
+func (b *atomicBucket) snapshot() Bucket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Bucket{
+		Sum:   b.sum,
+		Count: b.count,
+	}
+}
+
+// packState packs offset into the high offsetBits bits and lastTime
+// (truncated to lastTimeUnit resolution) into the remaining low bits.
+func packState(offset int, lastTime time.Duration) uint64 {
+	return uint64(offset)<<lastTimeBits | uint64(lastTime/lastTimeUnit)&lastTimeMask
+}
+
+func unpackState(word uint64) (offset int, lastTime time.Duration) {
+	offset = int(word >> lastTimeBits)
+	lastTime = time.Duration(word&lastTimeMask) * lastTimeUnit
+	return
+}
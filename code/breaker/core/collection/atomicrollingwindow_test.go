@@ -0,0 +1,84 @@
+package collection
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_AtomicRollingWindow_AddAndReduce(t *testing.T) {
+	w := NewAtomicRollingWindow(5, time.Minute)
+
+	for _, v := range []float64{5, 15, 15, 40} {
+		w.Add(v)
+	}
+
+	buckets := w.Reduce(nil)
+	var sum float64
+	var count int64
+	for _, b := range buckets {
+		sum += b.Sum
+		count += b.Count
+	}
+	if sum != 75 {
+		t.Fatalf("summed Sum = %v, want 75", sum)
+	}
+	if count != 4 {
+		t.Fatalf("summed Count = %v, want 4", count)
+	}
+}
+
+func Test_AtomicRollingWindow_IgnoreCurrentAtomicBucket(t *testing.T) {
+	w := NewAtomicRollingWindow(5, time.Minute, IgnoreCurrentAtomicBucket())
+	w.Add(10)
+
+	buckets := w.Reduce(nil)
+	var count int64
+	for _, b := range buckets {
+		count += b.Count
+	}
+	if count != 0 {
+		t.Fatalf("summed Count = %v, want 0 with the current, still-filling bucket ignored", count)
+	}
+}
+
+// Test_AtomicRollingWindow_ConcurrentAddPreservesCounts drives many goroutines
+// through a fixed number of Add calls concurrently, all landing in the same
+// bucket, then checks that every Add actually landed. The interval is
+// deliberately huge (and the workload small) so the run can't possibly take
+// long enough for any bucket to legitimately roll off the ring; any shortfall
+// in the summed Count is therefore real data loss, not an expired bucket. For
+// the same reason the window never advances during the run, so this test
+// covers concurrent Adds into one bucket, not concurrent advance() calls.
+func Test_AtomicRollingWindow_ConcurrentAddPreservesCounts(t *testing.T) {
+	const (
+		size      = 4
+		interval  = time.Hour // far longer than this test could ever run
+		workers   = 32
+		perWorker = 2000
+		totalAdds = workers * perWorker
+	)
+
+	w := NewAtomicRollingWindow(size, interval)
+
+	var wait sync.WaitGroup
+	wait.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wait.Done()
+			for j := 0; j < perWorker; j++ {
+				w.Add(1)
+			}
+		}()
+	}
+	wait.Wait()
+
+	var sum int64
+	for _, b := range w.Reduce(nil) {
+		sum += b.Count
+	}
+
+	if sum != totalAdds {
+		t.Fatalf("Reduce summed Count = %d, want %d (some concurrent Adds were lost)", sum, totalAdds)
+	}
+}
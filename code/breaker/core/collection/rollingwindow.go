@@ -1,6 +1,7 @@
 package collection
 
 import (
+	"math"
 	"sync"
 	"time"
 
@@ -23,6 +24,18 @@ type (
 	}
 )
 
+// WithHistogram lets the RollingWindow track a fixed-bucket histogram of the
+// added values alongside sum/count, so that percentiles can be approximated
+// later without keeping unbounded raw samples. bounds must be sorted
+// ascending; a value falls into the first bound it is less than or equal to,
+// or the overflow bin if it exceeds every bound.
+// 给每个 bucket 附加一个固定区间的直方图，用于近似计算分位数
+func WithHistogram(bounds []float64) RollingWindowOption {
+	return func(w *RollingWindow) {
+		w.win.setHistogramBounds(bounds)
+	}
+}
+
 // 初始化滑动窗口
 // NewRollingWindow returns a RollingWindow that with size buckets and time interval,
 // use opts to customize the RollingWindow.
@@ -103,12 +116,17 @@ func (rw *RollingWindow) updateOffset() {
 }
 
 // Bucket defines the bucket that holds sum and num of additions.
+// Histogram is only populated when the owning RollingWindow was built
+// with WithHistogram, and counts how many additions fell into each bin
+// of the shared bounds.
 // 桶
 // sum 数据总量
 // count 桶数量
+// histogram 可选的分位直方图，各 bin 的命中次数
 type Bucket struct {
-	Sum   float64
-	Count int64
+	Sum       float64
+	Count     int64
+	Histogram []int64
 }
 
 // 添加数据
@@ -117,16 +135,42 @@ func (b *Bucket) add(v float64) {
 	b.Count++
 }
 
+// 记录 v 落入的直方图 bin
+func (b *Bucket) addToHistogram(bounds []float64, v float64) {
+	if len(bounds) == 0 {
+		return
+	}
+	if b.Histogram == nil {
+		b.Histogram = make([]int64, len(bounds)+1)
+	}
+	b.Histogram[searchBound(bounds, v)]++
+}
+
+// searchBound returns the index of the first bound that v is <= to, or
+// len(bounds) for the overflow bin.
+func searchBound(bounds []float64, v float64) int {
+	for i, bound := range bounds {
+		if v <= bound {
+			return i
+		}
+	}
+	return len(bounds)
+}
+
 // 桶重置
 func (b *Bucket) reset() {
 	b.Sum = 0
 	b.Count = 0
+	for i := range b.Histogram {
+		b.Histogram[i] = 0
+	}
 }
 
 // 滑动窗口（环形数组）
 type window struct {
-	buckets []*Bucket
-	size    int
+	buckets         []*Bucket
+	size            int
+	histogramBounds []float64 // 直方图区间边界，升序排列，nil 表示不统计直方图
 }
 
 // 初始化窗口
@@ -141,9 +185,16 @@ func newWindow(size int) *window {
 	}
 }
 
+// setHistogramBounds 设置直方图区间边界，后续 add 会据此统计分布
+func (w *window) setHistogramBounds(bounds []float64) {
+	w.histogramBounds = bounds
+}
+
 // 往执行的 bucket 加入指定的指标
 func (w *window) add(offset int, v float64) {
-	w.buckets[offset%w.size].add(v)
+	b := w.buckets[offset%w.size]
+	b.add(v)
+	b.addToHistogram(w.histogramBounds, v)
 }
 
 // 遍历 buckets
@@ -165,3 +216,185 @@ func IgnoreCurrentBucket() RollingWindowOption {
 		w.ignoreCurrent = true
 	}
 }
+
+// Aggregation reports basic statistics over the buckets currently held by a
+// RollingWindow. Min/Max are the smallest/largest per-bucket average, since a
+// Bucket itself only retains a sum and a count; Avg is the total sum divided
+// by the total count across the window. Percentile further requires the
+// window to have been built with WithHistogram.
+// 对窗口内的 bucket 做统计：最小/最大是基于每个 bucket 的均值计算的，
+// 因为 Bucket 本身只保留了 sum 和 count；平均值是窗口内总和除以总次数；
+// Percentile 还需要窗口开启了直方图统计
+type Aggregation interface {
+	Min() float64
+	Max() float64
+	Avg() float64
+	Sum() float64
+	Count() int64
+	// Percentile returns an approximation of the p-th percentile (0 < p < 1),
+	// e.g. Percentile(0.99) for P99. It returns 0 if no histogram was configured.
+	Percentile(p float64) float64
+}
+
+// 统计结果的默认实现
+type aggregation struct {
+	min       float64
+	max       float64
+	sum       float64
+	count     int64
+	histogram []int64
+	bounds    []float64
+}
+
+func (a *aggregation) Min() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.min
+}
+
+func (a *aggregation) Max() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.max
+}
+
+func (a *aggregation) Avg() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.sum / float64(a.count)
+}
+
+func (a *aggregation) Sum() float64 {
+	return a.sum
+}
+
+func (a *aggregation) Count() int64 {
+	return a.count
+}
+
+func (a *aggregation) Percentile(p float64) float64 {
+	if a.count == 0 || len(a.histogram) == 0 {
+		return 0
+	}
+
+	target := float64(a.count) * p
+	var cumulative int64
+	for i, hits := range a.histogram {
+		cumulative += hits
+		if float64(cumulative) >= target {
+			if i < len(a.bounds) {
+				return a.bounds[i]
+			}
+			return a.bounds[len(a.bounds)-1]
+		}
+	}
+
+	return a.bounds[len(a.bounds)-1]
+}
+
+// Aggregation walks the current buckets and returns an Aggregation snapshot
+// of them. It holds the same RLock as Reduce, so it is safe for concurrent use.
+// 汇总当前窗口内的 bucket，返回一份统计快照
+func (rw *RollingWindow) Aggregation() Aggregation {
+	result := &aggregation{min: math.MaxFloat64, bounds: rw.win.histogramBounds}
+
+	rw.Reduce(func(b *Bucket) {
+		if b.Count == 0 {
+			return
+		}
+
+		result.sum += b.Sum
+		result.count += b.Count
+		avg := b.Sum / float64(b.Count)
+		if avg < result.min {
+			result.min = avg
+		}
+		if avg > result.max {
+			result.max = avg
+		}
+
+		if len(b.Histogram) > 0 {
+			if result.histogram == nil {
+				result.histogram = make([]int64, len(b.Histogram))
+			}
+			for i, hits := range b.Histogram {
+				result.histogram[i] += hits
+			}
+		}
+	})
+
+	return result
+}
+
+// RollingCounter is a thin wrapper around a RollingWindow that tracks a
+// monotonically accumulated count, e.g. request or error counts per window.
+// RollingCounter 是对 RollingWindow 的简单封装，用于统计窗口内的累加计数
+type RollingCounter struct {
+	window *RollingWindow
+}
+
+// NewRollingCounter returns a RollingCounter with size buckets and the given interval.
+func NewRollingCounter(size int, interval time.Duration, opts ...RollingWindowOption) *RollingCounter {
+	return &RollingCounter{
+		window: NewRollingWindow(size, interval, opts...),
+	}
+}
+
+// Add increases the counter in the current bucket by delta.
+func (rc *RollingCounter) Add(delta int64) {
+	rc.window.Add(float64(delta))
+}
+
+// Value returns the sum of the counter over the window.
+func (rc *RollingCounter) Value() int64 {
+	return int64(rc.window.Aggregation().Sum())
+}
+
+// Aggregation exposes the underlying window's aggregation, e.g. for percentiles.
+func (rc *RollingCounter) Aggregation() Aggregation {
+	return rc.window.Aggregation()
+}
+
+// RollingGauge is a thin wrapper around a RollingWindow that tracks a value
+// that goes up and down, e.g. inflight requests or queue depth.
+// RollingGauge 是对 RollingWindow 的简单封装，用于统计窗口内可增可减的瞬时值
+type RollingGauge struct {
+	window *RollingWindow
+}
+
+// NewRollingGauge returns a RollingGauge with size buckets and the given interval.
+func NewRollingGauge(size int, interval time.Duration, opts ...RollingWindowOption) *RollingGauge {
+	return &RollingGauge{
+		window: NewRollingWindow(size, interval, opts...),
+	}
+}
+
+// Update records v as the current observation of the gauge.
+func (rg *RollingGauge) Update(v float64) {
+	rg.window.Add(v)
+}
+
+// Value returns the average of the gauge's observations over the window.
+func (rg *RollingGauge) Value() float64 {
+	return rg.window.Aggregation().Avg()
+}
+
+// Last returns the average of the most recent non-empty bucket, which is
+// closer to "the current value" than Value's whole-window average.
+func (rg *RollingGauge) Last() float64 {
+	var last float64
+	rg.window.Reduce(func(b *Bucket) {
+		if b.Count > 0 {
+			last = b.Sum / float64(b.Count)
+		}
+	})
+	return last
+}
+
+// Aggregation exposes the underlying window's aggregation, e.g. for percentiles.
+func (rg *RollingGauge) Aggregation() Aggregation {
+	return rg.window.Aggregation()
+}
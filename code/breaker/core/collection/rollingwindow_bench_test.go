@@ -0,0 +1,33 @@
+package collection
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// BenchmarkRollingWindowAdd compares the mutex-based RollingWindow against
+// AtomicRollingWindow under increasing contention, to justify the added
+// complexity of the lock-free version.
+func BenchmarkRollingWindowAdd(b *testing.B) {
+	for _, goroutines := range []int{1, 8, 64, 256} {
+		b.Run("mutex/"+strconv.Itoa(goroutines), func(b *testing.B) {
+			w := NewRollingWindow(50, time.Millisecond*100)
+			benchmarkAdd(b, goroutines, w.Add)
+		})
+		b.Run("atomic/"+strconv.Itoa(goroutines), func(b *testing.B) {
+			w := NewAtomicRollingWindow(50, time.Millisecond*100)
+			benchmarkAdd(b, goroutines, w.Add)
+		})
+	}
+}
+
+func benchmarkAdd(b *testing.B, goroutines int, add func(v float64)) {
+	b.SetParallelism(goroutines)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			add(1)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package collection
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RollingWindow_Aggregation_WithHistogram(t *testing.T) {
+	bounds := []float64{10, 20, 50}
+	w := NewRollingWindow(5, time.Minute, WithHistogram(bounds))
+
+	for _, v := range []float64{5, 15, 15, 40} {
+		w.Add(v)
+	}
+
+	agg := w.Aggregation()
+	if got := agg.Sum(); got != 75 {
+		t.Fatalf("Sum() = %v, want 75", got)
+	}
+	if got := agg.Count(); got != 4 {
+		t.Fatalf("Count() = %v, want 4", got)
+	}
+	// all four adds land in the same (current) bucket, so Min/Max/Avg are all
+	// that one bucket's average
+	want := 75.0 / 4
+	if got := agg.Avg(); got != want {
+		t.Fatalf("Avg() = %v, want %v", got, want)
+	}
+	if got := agg.Min(); got != want {
+		t.Fatalf("Min() = %v, want %v", got, want)
+	}
+	if got := agg.Max(); got != want {
+		t.Fatalf("Max() = %v, want %v", got, want)
+	}
+
+	// histogram: 5 -> bin 0 (<=10), 15,15 -> bin 1 (<=20), 40 -> bin 2 (<=50)
+	// P50 lands on the 2nd hit, which is in bin 1 (bound 20)
+	if got := agg.Percentile(0.5); got != 20 {
+		t.Fatalf("Percentile(0.5) = %v, want 20", got)
+	}
+	// P99 needs the 4th hit, which is in bin 2 (bound 50)
+	if got := agg.Percentile(0.99); got != 50 {
+		t.Fatalf("Percentile(0.99) = %v, want 50", got)
+	}
+}
+
+func Test_RollingWindow_Aggregation_NoHistogram(t *testing.T) {
+	w := NewRollingWindow(5, time.Minute)
+	w.Add(10)
+
+	if got := w.Aggregation().Percentile(0.99); got != 0 {
+		t.Fatalf("Percentile(0.99) without WithHistogram = %v, want 0", got)
+	}
+}
+
+func Test_RollingWindow_Percentile_OverflowBinSaturates(t *testing.T) {
+	bounds := []float64{10, 20}
+	w := NewRollingWindow(5, time.Minute, WithHistogram(bounds))
+
+	for _, v := range []float64{5, 1000, 5000} {
+		w.Add(v)
+	}
+
+	// the two values above every bound fall into the overflow bin and
+	// Percentile has no upper bound to report beyond it, so it saturates at
+	// the last configured bound rather than returning the real value
+	if got := w.Aggregation().Percentile(0.99); got != bounds[len(bounds)-1] {
+		t.Fatalf("Percentile(0.99) = %v, want overflow bin to saturate at %v", got, bounds[len(bounds)-1])
+	}
+}
+
+func Test_RollingCounter_AddAndValue(t *testing.T) {
+	rc := NewRollingCounter(5, time.Minute)
+	rc.Add(3)
+	rc.Add(4)
+
+	if got := rc.Value(); got != 7 {
+		t.Fatalf("Value() = %v, want 7", got)
+	}
+}
+
+func Test_RollingGauge_UpdateValueAndLast(t *testing.T) {
+	rg := NewRollingGauge(5, time.Minute)
+	rg.Update(10)
+	rg.Update(20)
+
+	if got := rg.Value(); got != 15 {
+		t.Fatalf("Value() = %v, want 15", got)
+	}
+	if got := rg.Last(); got != 15 {
+		t.Fatalf("Last() = %v, want 15 (most recent non-empty bucket is still the current one)", got)
+	}
+}
@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const (
+	// TraceName is the tracer name used throughout the handlers.
+	TraceName = "go-zero"
+	// TraceIdKey is the header/attribute key used to surface the trace id.
+	TraceIdKey = "X-Trace-Id"
+)
+
+// Batcher picks which SpanExporter StartAgent builds.
+type Batcher string
+
+const (
+	BatcherJaeger   Batcher = "jaeger"
+	BatcherZipkin   Batcher = "zipkin"
+	BatcherOtlpGrpc Batcher = "otlpgrpc"
+	BatcherOtlpHttp Batcher = "otlphttp"
+)
+
+// Config is the minimal set of knobs needed to ship spans out of process.
+// 启动链路追踪所需的配置：导出到哪（Endpoint）、用哪种协议（Batcher）、采样率等
+type Config struct {
+	Name     string  // service name, becomes the service.name resource attribute
+	Endpoint string  // collector endpoint, e.g. "otel-collector:4317"
+	Sampler  float64 // fraction of traces to sample, 0 < Sampler <= 1
+	Batcher  Batcher // "jaeger", "zipkin", "otlpgrpc" or "otlphttp"
+	Insecure bool    // skip TLS when talking to Endpoint, for otlpgrpc/otlphttp only
+	Headers  map[string]string
+}
+
+// StartAgent builds the SpanExporter matching c.Batcher, wires it into a
+// BatchSpanProcessor and a TracerProvider tagged with c.Name, registers that
+// provider globally, and installs a TraceContext+Baggage propagator so
+// TracingHandler's baggage extraction has something to read. This is the
+// piece that was missing between "span created" and "span shipped": without
+// calling it, TracingHandler still creates spans, they just never leave the
+// process.
+// 构建对应 Batcher 的导出器，注册全局 TracerProvider 和 TraceContext+Baggage 传播器
+func StartAgent(c Config) (func(context.Context) error, error) {
+	exporter, err := newExporter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(semconv.ServiceNameKey.String(c.Name)))
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.Sampler))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(c Config) (sdktrace.SpanExporter, error) {
+	switch c.Batcher {
+	case BatcherJaeger:
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(c.Endpoint)))
+	case BatcherZipkin:
+		return zipkin.New(c.Endpoint)
+	case BatcherOtlpGrpc:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(c.Headers))
+		}
+		return otlptracegrpc.New(context.Background(), opts...)
+	case BatcherOtlpHttp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown batcher: %q", c.Batcher)
+	}
+}
@@ -0,0 +1,344 @@
+package limit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Acquire when the bounded queue has no room for
+// the waiter even after evicting the largest tenant's oldest waiter.
+var ErrQueueFull = errors.New("limit: bounded queue is full")
+
+// retryInterval is how often the dispatcher re-tries the inner limiter on
+// behalf of the queue's head-of-line waiters.
+const retryInterval = time.Millisecond * 10
+
+// quantumBytes is the deficit round-robin quantum handed to a tenant on each
+// pass: a tenant whose head waiter costs more than its accumulated deficit
+// is skipped for that pass rather than dispatched, so a tenant sending
+// larger requests gets serviced proportionally less often than one sending
+// many small ones, instead of getting an equal turn regardless of cost.
+const quantumBytes int64 = 4096
+
+type (
+	// Limiter is the common admission check shared by TokenLimiter and
+	// SlidingWindowLimiter, so BoundedQueueLimiter can wrap either.
+	Limiter interface {
+		Allow() bool
+	}
+
+	waiter struct {
+		tenant    string
+		bytes     int64
+		elem      *list.Element
+		done      chan struct{}
+		err       error
+		finalized bool
+	}
+
+	// BoundedQueueLimiter wraps an inner Limiter with a bounded, per-tenant
+	// weighted-fair queue: a request that the inner limiter would otherwise
+	// reject is parked instead of dropped, and retried using deficit
+	// round-robin across tenants once the inner limiter has room again, so
+	// tenants sending larger requests don't get the same share of turns as
+	// ones sending small, cheap ones. Both the waiter count and the combined
+	// byte cost of queued and admitted-but-not-yet-released callers are bounded; when
+	// either is exceeded, the oldest waiter belonging to whichever tenant has
+	// the most waiters is evicted to make room, so one noisy tenant can't
+	// starve the others out.
+	// 在现有限流器前加一层有界的按租户加权公平排队的准入队列，避免突发流量被直接拒绝
+	BoundedQueueLimiter struct {
+		lock         sync.Mutex
+		inner        Limiter
+		maxWaiters   int
+		maxWaitBytes int64
+		waiterCount  int
+		usedBytes    int64
+		queues       map[string]*list.List
+		deficits     map[string]int64
+		order        []string
+		rrIndex      int
+		wake         chan struct{}
+		started      bool
+	}
+)
+
+// NewBoundedQueueLimiter returns a BoundedQueueLimiter around inner, allowing
+// at most maxWaiters parked callers and maxWaitBytes worth of queued callers
+// plus admitted callers that haven't released yet.
+func NewBoundedQueueLimiter(maxWaiters int, maxWaitBytes int64, inner Limiter) *BoundedQueueLimiter {
+	return &BoundedQueueLimiter{
+		inner:        inner,
+		maxWaiters:   maxWaiters,
+		maxWaitBytes: maxWaitBytes,
+		queues:       make(map[string]*list.List),
+		deficits:     make(map[string]int64),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Acquire admits tenant's request of the given estimated byte cost, either
+// immediately or after waiting in the bounded queue, and returns a release
+// func the caller must call once done so the byte budget frees up for
+// others. If ctx is canceled while queued, Acquire returns ctx.Err() and the
+// waiter is removed from the queue; it never leaks a parked goroutine.
+func (l *BoundedQueueLimiter) Acquire(ctx context.Context, tenant string, bytes int64) (func(), error) {
+	if l.tryAdmitDirect(bytes) {
+		return l.release(bytes), nil
+	}
+
+	w, err := l.enqueue(tenant, bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	l.ensureDispatcher()
+
+	select {
+	case <-w.done:
+		if w.err != nil {
+			return nil, w.err
+		}
+		return l.release(bytes), nil
+	case <-ctx.Done():
+		l.cancel(w, ctx.Err())
+		<-w.done
+		if w.err != nil {
+			return nil, w.err
+		}
+		return l.release(bytes), nil
+	}
+}
+
+// tryAdmitDirect lets a request through without ever touching the queue when
+// the inner limiter already allows it and there's budget for it, which keeps
+// the common, uncongested path cheap. The byte budget is reserved before the
+// inner limiter is even consulted, and given back if the inner limiter
+// denies, so a consumed inner token is never silently discarded on a budget
+// miss.
+func (l *BoundedQueueLimiter) tryAdmitDirect(bytes int64) bool {
+	l.lock.Lock()
+	if l.waiterCount > 0 || l.usedBytes+bytes > l.maxWaitBytes {
+		l.lock.Unlock()
+		return false
+	}
+	l.usedBytes += bytes
+	l.lock.Unlock()
+
+	if l.inner.Allow() {
+		return true
+	}
+
+	l.lock.Lock()
+	l.usedBytes -= bytes
+	l.lock.Unlock()
+	l.signal()
+	return false
+}
+
+func (l *BoundedQueueLimiter) release(bytes int64) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			l.usedBytes -= bytes
+			l.lock.Unlock()
+			l.signal()
+		})
+	}
+}
+
+func (l *BoundedQueueLimiter) enqueue(tenant string, bytes int64) (*waiter, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for l.waiterCount >= l.maxWaiters || l.usedBytes+bytes > l.maxWaitBytes {
+		if !l.evictLargestTenantLocked() {
+			return nil, ErrQueueFull
+		}
+	}
+
+	w := &waiter{tenant: tenant, bytes: bytes, done: make(chan struct{})}
+	q, ok := l.queues[tenant]
+	if !ok {
+		q = list.New()
+		l.queues[tenant] = q
+		l.order = append(l.order, tenant)
+	}
+	w.elem = q.PushBack(w)
+	l.waiterCount++
+	l.usedBytes += bytes
+
+	return w, nil
+}
+
+// evictLargestTenantLocked drops the oldest waiter of whichever tenant
+// currently has the most queued waiters, to make room for a new arrival.
+// Caller must hold l.lock.
+func (l *BoundedQueueLimiter) evictLargestTenantLocked() bool {
+	var largest string
+	var largestLen int
+	for tenant, q := range l.queues {
+		if q.Len() > largestLen {
+			largest = tenant
+			largestLen = q.Len()
+		}
+	}
+	if largestLen == 0 {
+		return false
+	}
+
+	q := l.queues[largest]
+	front := q.Front()
+	w := front.Value.(*waiter)
+	q.Remove(front)
+	l.waiterCount--
+	l.usedBytes -= w.bytes
+	if q.Len() == 0 {
+		delete(l.queues, largest)
+		l.removeFromOrderLocked(largest)
+	}
+	l.finalizeLocked(w, ErrQueueFull)
+
+	return true
+}
+
+func (l *BoundedQueueLimiter) cancel(w *waiter, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if w.finalized {
+		return
+	}
+
+	q := l.queues[w.tenant]
+	if q != nil {
+		q.Remove(w.elem)
+		l.waiterCount--
+		l.usedBytes -= w.bytes
+		if q.Len() == 0 {
+			delete(l.queues, w.tenant)
+			l.removeFromOrderLocked(w.tenant)
+		}
+	}
+	l.finalizeLocked(w, err)
+}
+
+func (l *BoundedQueueLimiter) finalizeLocked(w *waiter, err error) {
+	if w.finalized {
+		return
+	}
+	w.finalized = true
+	w.err = err
+	close(w.done)
+}
+
+func (l *BoundedQueueLimiter) removeFromOrderLocked(tenant string) {
+	delete(l.deficits, tenant)
+	for i, t := range l.order {
+		if t == tenant {
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			if l.rrIndex > i {
+				l.rrIndex--
+			}
+			return
+		}
+	}
+}
+
+// signal wakes the dispatcher immediately instead of leaving it to find out
+// about freed budget on the next retryInterval tick.
+func (l *BoundedQueueLimiter) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ensureDispatcher starts the single background goroutine that retries the
+// inner limiter on behalf of queued waiters, round-robin across tenants. It
+// only starts once, the first time a caller actually needs to queue.
+func (l *BoundedQueueLimiter) ensureDispatcher() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.started {
+		return
+	}
+	l.started = true
+	go l.dispatch()
+}
+
+// dispatch retries the inner limiter on behalf of queued waiters using
+// deficit round-robin: each tenant accrues quantumBytes of "credit" every
+// time its turn comes around, and its head waiter is only dispatched once
+// that credit covers the waiter's byte cost. A tenant with cheap waiters gets
+// serviced almost every turn; a tenant with expensive ones has to wait out
+// several turns accruing credit first, which is what makes the scheduling
+// weighted by cost rather than a plain per-tenant round robin.
+//
+// A single wake or tick drains as many waiters as the inner limiter will
+// admit, not just one: otherwise the queue could only ever drain at
+// 1/retryInterval regardless of how much headroom the inner limiter actually
+// has, defeating the point of smoothing a burst instead of dropping it.
+func (l *BoundedQueueLimiter) dispatch() {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.wake:
+		}
+
+		l.drain()
+	}
+}
+
+// drain services turns round-robin across l.order until either the inner
+// limiter denies one (no more admission headroom right now) or a full lap
+// goes by without dispatching anyone (every head waiter is still short on
+// accrued deficit), at which point it's left to the next tick or signal.
+func (l *BoundedQueueLimiter) drain() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	noProgress := 0
+	for len(l.order) > 0 && noProgress < len(l.order) {
+		tenant := l.order[l.rrIndex%len(l.order)]
+		l.rrIndex++
+
+		q := l.queues[tenant]
+		if q == nil || q.Len() == 0 {
+			noProgress++
+			continue
+		}
+
+		l.deficits[tenant] += quantumBytes
+		front := q.Front()
+		w := front.Value.(*waiter)
+		if l.deficits[tenant] < w.bytes {
+			// not enough accrued credit yet for this tenant's head waiter;
+			// carry the deficit forward and let another tenant have this turn
+			noProgress++
+			continue
+		}
+
+		if !l.inner.Allow() {
+			// no admission headroom right now; wait for the next tick or signal
+			return
+		}
+
+		l.deficits[tenant] -= w.bytes
+		q.Remove(front)
+		l.waiterCount--
+		if q.Len() == 0 {
+			delete(l.queues, tenant)
+			l.removeFromOrderLocked(tenant)
+		}
+		l.finalizeLocked(w, nil)
+		noProgress = 0
+	}
+}
@@ -0,0 +1,137 @@
+package limit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const slidingWindowFormat = "{%s}.sliding"
+
+// slidingWindowLuaScript keeps 2*buckets counters in a redis hash, one
+// counter and one timestamp per slot, so that both the in-progress current
+// window and the complete previous window are available as distinct sums at
+// the same time (a ring sized to exactly one window can't hold both, since
+// the current window's slots are the previous window's slots one cycle
+// earlier). Each slot is classified by comparing its own last-write
+// timestamp against the current window's start, not by its position in the
+// ring: a slot's ring index only tells you which window *parity* it was
+// last written in, not whether it's actually been touched since the current
+// window began, and a plain index-based split double-counts a slot that
+// hasn't been reached yet this cycle using its two-windows-stale value. The
+// slot about to receive this request is reset to 1 instead of incremented
+// when it's being touched for the first time this window, so a stale count
+// left over from two windows ago never leaks into the new window's sum.
+// It admits the request only if
+// sum(currentWindowSlots) + sum(previousWindowSlots)*(1-elapsedFractionOfCurrentWindow)
+// stays under rate, which is the actual per-window cap the limiter promises,
+// not a per-bucket one.
+// KEYS[1]: the hash key
+// ARGV[1]: now in ms
+// ARGV[2]: bucket interval in ms
+// ARGV[3]: number of buckets per window
+// ARGV[4]: rate, i.e. max requests allowed per window
+const slidingWindowLuaScript = `
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local buckets = tonumber(ARGV[3])
+local rate = tonumber(ARGV[4])
+local window = interval * buckets
+local ringSize = buckets * 2
+
+local currentSlot = math.floor(now / interval) % ringSize
+local windowStart = now - (now % window)
+local prevWindowStart = windowStart - window
+
+local curSum = 0
+local prevSum = 0
+local currentSlotTs = 0
+for i = 0, ringSize - 1 do
+    local ts = tonumber(redis.call("HGET", KEYS[1], "t" .. i)) or 0
+    local count = tonumber(redis.call("HGET", KEYS[1], "c" .. i)) or 0
+
+    if ts >= windowStart then
+        curSum = curSum + count
+    elseif ts >= prevWindowStart then
+        prevSum = prevSum + count
+    end
+
+    if i == currentSlot then
+        currentSlotTs = ts
+    end
+end
+
+local elapsedFraction = (now - windowStart) / window
+local count = curSum + prevSum * (1 - elapsedFraction)
+
+if count < rate then
+    if currentSlotTs < windowStart then
+        redis.call("HSET", KEYS[1], "c" .. currentSlot, 1)
+    else
+        redis.call("HINCRBY", KEYS[1], "c" .. currentSlot, 1)
+    end
+    redis.call("HSET", KEYS[1], "t" .. currentSlot, now)
+    redis.call("PEXPIRE", KEYS[1], window * 2)
+    return 1
+end
+return 0
+`
+
+// A SlidingWindowLimiter smooths out the request-count across a sliding
+// window of buckets, instead of the burst-then-refill shape of a token
+// bucket, using the same ring-of-buckets idea as collection.RollingWindow but
+// backed by redis so multiple instances can share one limit.
+// 滑动窗口请求数限流器，用 redis hash 维护多个 bucket 的计数，避免令牌桶补充瞬间的毛刺
+type SlidingWindowLimiter struct {
+	rate       int
+	window     time.Duration
+	buckets    int
+	intervalMs int64
+	store      *redis.Redis
+	key        string
+}
+
+// NewSlidingWindowLimiter returns a SlidingWindowLimiter that allows at most
+// rate requests within window, tracked across buckets sub-intervals of it.
+func NewSlidingWindowLimiter(rate int, window time.Duration, buckets int, store *redis.Redis, key string) *SlidingWindowLimiter {
+	if buckets < 1 {
+		panic("buckets must be greater than 0")
+	}
+
+	return &SlidingWindowLimiter{
+		rate:       rate,
+		window:     window,
+		buckets:    buckets,
+		intervalMs: window.Milliseconds() / int64(buckets),
+		store:      store,
+		key:        fmt.Sprintf(slidingWindowFormat, key),
+	}
+}
+
+// Allow reports whether a request is allowed right now.
+func (l *SlidingWindowLimiter) Allow() bool {
+	nowMs := time.Now().UnixMilli()
+	resp, err := l.store.Eval(slidingWindowLuaScript, []string{l.key},
+		[]string{
+			strconv.FormatInt(nowMs, 10),
+			strconv.FormatInt(l.intervalMs, 10),
+			strconv.Itoa(l.buckets),
+			strconv.Itoa(l.rate),
+		})
+	if err != nil {
+		logx.Errorf("fail to use sliding window limiter: %s, allow the request", err)
+		// fail open, consistent with the rescue behavior of the token limiter
+		return true
+	}
+
+	code, ok := resp.(int64)
+	if !ok {
+		logx.Errorf("fail to parse sliding window limiter response: %v", resp)
+		return true
+	}
+
+	return code == 1
+}
@@ -0,0 +1,138 @@
+package limit_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gozerosource/limit/core/limit"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stores/redis/redistest"
+)
+
+// Test_SlidingWindowLimiter_RateEnforced asserts the actual promise of the
+// limiter: a burst thrown at it within a single window never admits more
+// than rate requests, regardless of how many buckets it's split into. This
+// is the case the printf-only Test_SlidingWindowLimiter below can't catch.
+func Test_SlidingWindowLimiter_RateEnforced(t *testing.T) {
+	store := redistest.CreateRedis(t)
+
+	const (
+		rate    = 10
+		buckets = 5
+		window  = time.Second
+	)
+	limiter := limit.NewSlidingWindowLimiter(rate, window, buckets, store, "sliding-window-rate-test")
+
+	var allowed int
+	for i := 0; i < rate*buckets; i++ {
+		if limiter.Allow() {
+			allowed++
+		}
+	}
+
+	if allowed > rate {
+		t.Fatalf("expected at most %d allowed requests within one window, got %d", rate, allowed)
+	}
+}
+
+// Test_SlidingWindowLimiter_SustainedLoadAcrossWindowBoundary drives a steady,
+// at-capacity pace across several window boundaries instead of a single
+// fresh-window burst. A correctly window-aligned split keeps admitting
+// requests every tick; a buggy age-from-currentSlot split stalls to near-zero
+// right after a boundary, since it misclassifies several tail buckets of the
+// true previous window as "current". The offered load never exceeds rate per
+// window, so the upper-bound check below is only a sanity bound and cannot
+// detect over-admission.
+func Test_SlidingWindowLimiter_SustainedLoadAcrossWindowBoundary(t *testing.T) {
+	store := redistest.CreateRedis(t)
+
+	const (
+		rate       = 100
+		buckets    = 10
+		window     = time.Second
+		perBucket  = rate / buckets
+		totalTicks = buckets * 3 // three full windows
+	)
+	limiter := limit.NewSlidingWindowLimiter(rate, window, buckets, store, "sliding-window-boundary-test")
+	interval := window / buckets
+
+	allowedPerTick := make([]int, totalTicks)
+	for tick := 0; tick < totalTicks; tick++ {
+		deadline := time.Now().Add(interval)
+		for i := 0; i < perBucket; i++ {
+			if limiter.Allow() {
+				allowedPerTick[tick]++
+			}
+			time.Sleep(interval / perBucket)
+		}
+		time.Sleep(time.Until(deadline))
+	}
+
+	// Skip the first window: it's still filling up, so its early ticks are
+	// legitimately under capacity. From the second window on, no
+	// window-length slice of ticks may exceed rate by more than one bucket,
+	// and no single tick may stall to zero the way the buggy grouping did.
+	for tick := buckets; tick < totalTicks; tick++ {
+		windowSum := 0
+		for i := tick - buckets + 1; i <= tick; i++ {
+			windowSum += allowedPerTick[i]
+		}
+		if windowSum > rate+buckets {
+			t.Fatalf("tick %d: window-aligned sum %d exceeds rate %d by more than one bucket's slack", tick, windowSum, rate)
+		}
+	}
+	for tick := buckets + 1; tick < totalTicks; tick++ {
+		if allowedPerTick[tick] == 0 {
+			t.Fatalf("tick %d: admitted nothing under sustained at-capacity load, expected ~%d", tick, perBucket)
+		}
+	}
+}
+
+func Test_SlidingWindowLimiter(t *testing.T) {
+	const (
+		rate    = 100
+		buckets = 10
+		window  = time.Second
+		seconds = 5
+	)
+	store := redis.New("127.0.0.1:6379")
+	fmt.Println(store.Ping())
+	// New slidingWindowLimiter
+	limiter := limit.NewSlidingWindowLimiter(rate, window, buckets, store, "sliding-window-limiter")
+	timer := time.NewTimer(time.Second * seconds)
+	quit := make(chan struct{})
+	defer timer.Stop()
+	go func() {
+		<-timer.C
+		close(quit)
+	}()
+
+	var allowed, denied int32
+	var wait sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wait.Add(1)
+		go func() {
+			for {
+				select {
+				case <-quit:
+					wait.Done()
+					return
+				default:
+					if limiter.Allow() {
+						atomic.AddInt32(&allowed, 1)
+					} else {
+						atomic.AddInt32(&denied, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	wait.Wait()
+	fmt.Printf("allowed: %d, denied: %d, qps: %d\n", allowed, denied, (allowed+denied)/seconds)
+}
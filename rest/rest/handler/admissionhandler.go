@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"gozerosource/code/admission/core/load/admission"
+)
+
+// AdmissionHandler return a middleware that sheds load once the service's
+// AdmissionController estimates it's approaching saturation, instead of
+// letting requests queue up behind a slow downstream dependency.
+// 自适应并发准入中间件，在服务接近饱和前主动拒绝请求
+func AdmissionHandler(ac *admission.AdmissionController) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := ac.Allow()
+			if !ok {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			start := time.Now()
+			writer := &codeResponseWriter{ResponseWriter: w, code: http.StatusOK}
+			next.ServeHTTP(writer, r)
+			token.Done(writer.code < http.StatusInternalServerError, time.Since(start))
+		})
+	}
+}
+
+// codeResponseWriter records the status code so AdmissionHandler can tell
+// AdmissionController whether the request succeeded.
+type codeResponseWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *codeResponseWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -1,18 +1,25 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 
-	"github.com/zeromicro/go-zero/core/trace"
+	"gozerosource/code/trace/core/trace"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // TracingHandler return a middleware that process the opentelemetry.
-// 链路追踪中间件
-func TracingHandler(serviceName, path string) func(http.Handler) http.Handler {
+// baggageKeys, if given, is an allow-list of baggage members that get copied
+// onto the span as attributes, so they show up in the trace backend instead
+// of only being readable from the propagated context.
+// 链路追踪中间件，baggageKeys 是允许写入 span 属性的 baggage key 白名单
+func TracingHandler(serviceName, path string, baggageKeys ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		propagator := otel.GetTextMapPropagator()
 		tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
@@ -38,7 +45,26 @@ func TracingHandler(serviceName, path string) func(http.Handler) http.Handler {
 				w.Header().Set(trace.TraceIdKey, sc.TraceID().String())
 			}
 
+			copyBaggageToSpan(spanCtx, span, baggageKeys)
+
 			next.ServeHTTP(w, r.WithContext(spanCtx))
 		})
 	}
 }
+
+// copyBaggageToSpan reads the W3C baggage already attached to ctx by the
+// propagator and copies the members named in keys onto span as attributes,
+// so they're queryable in the trace backend without decoding baggage headers
+// by hand.
+func copyBaggageToSpan(ctx context.Context, span oteltrace.Span, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	b := baggage.FromContext(ctx)
+	for _, key := range keys {
+		if member := b.Member(key); member.Key() != "" {
+			span.SetAttributes(attribute.String(member.Key(), member.Value()))
+		}
+	}
+}